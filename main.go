@@ -3,214 +3,87 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"os"
-	"path/filepath"
 	"sync"
 
-	"github.com/jcelliott/lumber"
+	"github.com/bakhtybayevn/simple-database/server"
+	"github.com/bakhtybayevn/simple-database/simpledb"
 )
 
-const Version = "1.0.0"
-
-type Logger interface {
-	Fatal(string, ...interface{})
-	Error(string, ...interface{})
-	Warn(string, ...interface{})
-	Info(string, ...interface{})
-	Debug(string, ...interface{})
-	Trace(string, ...interface{})
-}
-
-type Driver struct {
-	mu     sync.Mutex
-	caches map[string]*sync.Mutex
-	dir    string
-	log    Logger
-}
-
-type Options struct {
-	Logger
+type Address struct {
+	Street  string      `json:"street"`
+	City    string      `json:"city"`
+	Country string      `json:"country"`
+	Pincode json.Number `json:"pincode"`
 }
 
-func New(dir string, options *Options) (*Driver, error) {
-	dir = filepath.Clean(dir)
-	opts := Options{}
-	if options != nil {
-		opts = *options
-	}
-
-	if opts.Logger == nil {
-		opts.Logger = lumber.NewConsoleLogger(lumber.INFO)
-	}
-
-	driver := &Driver{
-		dir:    dir,
-		log:    opts.Logger,
-		caches: make(map[string]*sync.Mutex),
-	}
-
-	if _, err := os.Stat(dir); err != nil {
-		opts.Logger.Debug("Using '%s' (database already exists)\n", dir)
-		return driver, nil
-	}
-
-	opts.Logger.Debug("Creating the database at '%s'...\n", dir)
-	return driver, os.MkdirAll(dir, 0755)
+type User struct {
+	Name    string      `json:"name"`
+	Age     json.Number `json:"age"`
+	Contact string      `json:"contact"`
+	Company string      `json:"company"`
+	Address Address     `json:"address"`
 }
 
-func (d *Driver) Write(collection, resource string, v interface{}) error {
-	if collection == "" || resource == "" {
-		return fmt.Errorf("collection and resource must not be empty when writing a record")
-	}
-
-	mutex := d.getOrCreateMutex(collection)
-	mutex.Lock()
-	defer mutex.Unlock()
-
-	dir := filepath.Join(d.dir, collection)
-	fnlPath := filepath.Join(dir, resource+".json")
-	tmpPath := fnlPath + ".tmp"
-
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return err
-	}
-
-	b, err := json.MarshalIndent(v, "", "\t")
-	if err != nil {
-		return err
-	}
-
-	b = append(b, byte('\n'))
-
-	if err := ioutil.WriteFile(tmpPath, b, 0644); err != nil {
-		return err
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		runServe(os.Args[2:])
+		return
 	}
-
-	return os.Rename(tmpPath, fnlPath)
+	runDemo()
 }
 
-func (d *Driver) Read(collection, resource string, v interface{}) error {
-	if collection == "" || resource == "" {
-		return fmt.Errorf("collection and resource must not be empty when reading a record")
-	}
-
-	recordPath := filepath.Join(d.dir, collection, resource)
-	if _, err := Stat(recordPath); err != nil {
-		return err
-	}
-
-	b, err := ioutil.ReadFile(recordPath + ".json")
-	if err != nil {
-		return err
+// runServe wraps a Driver rooted at dir behind the REST API; see
+// server.Run for the supported flags.
+func runServe(args []string) {
+	if err := server.Run(args); err != nil {
+		fmt.Println("Error: ", err)
+		os.Exit(1)
 	}
-
-	return json.Unmarshal(b, &v)
 }
 
-func (d *Driver) ReadAll(collection string) ([]string, error) {
-	if collection == "" {
-		return nil, fmt.Errorf("collection must not be empty when reading all records")
-	}
-
-	dir := filepath.Join(d.dir, collection)
-	if _, err := Stat(dir); err != nil {
-		return nil, err
-	}
-
-	files, err := ioutil.ReadDir(dir)
-	if err != nil {
-		return nil, err
-	}
+// contactUniqueness rejects a write whose "contact" would duplicate one
+// already seen for a different user, demonstrating OnBeforeWrite as an
+// invariant RegisterSchema can't express on its own.
+func contactUniqueness() func(collection, resource string, payload []byte) error {
+	var mu sync.Mutex
+	seen := make(map[string]string) // contact -> resource that claimed it
 
-	records := make([]string, 0, len(files))
-	for _, file := range files {
-		if file.IsDir() {
-			continue
+	return func(collection, resource string, payload []byte) error {
+		if collection != "users" {
+			return nil
 		}
-
-		b, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
-		if err != nil {
-			return nil, err
+		var v struct {
+			Contact string `json:"contact"`
+		}
+		if err := json.Unmarshal(payload, &v); err != nil {
+			return err
 		}
 
-		records = append(records, string(b))
-	}
-
-	return records, nil
-}
-
-func (d *Driver) Delete(collection, resource string) error {
-	if collection == "" || resource == "" {
-		return fmt.Errorf("collection and resource must not be empty when deleting a record")
-	}
-
-	path := filepath.Join(collection, resource)
-	mutex := d.getOrCreateMutex(collection)
-	mutex.Lock()
-	defer mutex.Unlock()
-
-	dir := filepath.Join(d.dir, path)
-
-	switch fi, err := Stat(dir); {
-	case fi == nil, err != nil:
-		return fmt.Errorf("unable to delete '%s' because it does not exist", path)
-	case fi.Mode().IsDir():
-		return os.RemoveAll(dir)
-	case fi.Mode().IsRegular():
-		return os.RemoveAll(dir + ".json")
-	}
-
-	return nil
-}
-
-func (d *Driver) getOrCreateMutex(collection string) *sync.Mutex {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-	m, ok := d.caches[collection]
-	if !ok {
-		m = &sync.Mutex{}
-		d.caches[collection] = m
-	}
-	return m
-}
-
-func Stat(path string) (fi os.FileInfo, err error) {
-	if fi, err = os.Stat(path); os.IsNotExist(err) {
-		fi, err = os.Stat(path + ".json")
+		mu.Lock()
+		defer mu.Unlock()
+		if owner, ok := seen[v.Contact]; ok && owner != resource {
+			return fmt.Errorf("contact '%s' already belongs to '%s'", v.Contact, owner)
+		}
+		seen[v.Contact] = resource
+		return nil
 	}
-	return
-}
-
-type Address struct {
-	Street  string      `json:"street"`
-	City    string      `json:"city"`
-	Country string      `json:"country"`
-	Pincode json.Number `json:"pincode"`
-}
-
-type User struct {
-	Name    string      `json:"name"`
-	Age     json.Number `json:"age"`
-	Contact string      `json:"contact"`
-	Company string      `json:"company"`
-	Address Address     `json:"address"`
 }
 
-func main() {
+func runDemo() {
 	dir := "./"
-	db, err := New(dir, nil)
+	db, err := simpledb.New(dir, &simpledb.Options{OnBeforeWrite: contactUniqueness()})
 	if err != nil {
 		fmt.Println("Error: ", err)
 	}
 
 	employees := []User{
 		{"John", "30", "213", "ABC", Address{"Street 1", "City 1", "Country 1", "123456"}},
-		{"Paul", "27", "213", "Facebook", Address{"Street 2", "City 2", "Country 2", "123456"}},
-		{"Jessica", "22", "213", "Google", Address{"Street 3", "City 3", "Country 3", "123456"}},
-		{"Akhil", "34", "213", "Meta", Address{"Street 4", "City 4", "Country 4", "123456"}},
-		{"Alba", "42", "213", "Amazon", Address{"Street 5", "City 5", "Country 5", "123456"}},
-		{"Stipe", "45", "213", "Yandex", Address{"Street 6", "City 6", "Country 6", "123456"}},
+		{"Paul", "27", "214", "Facebook", Address{"Street 2", "City 2", "Country 2", "123456"}},
+		{"Jessica", "22", "215", "Google", Address{"Street 3", "City 3", "Country 3", "123456"}},
+		{"Akhil", "34", "216", "Meta", Address{"Street 4", "City 4", "Country 4", "123456"}},
+		{"Alba", "42", "217", "Amazon", Address{"Street 5", "City 5", "Country 5", "123456"}},
+		{"Stipe", "45", "218", "Yandex", Address{"Street 6", "City 6", "Country 6", "123456"}},
 	}
 
 	for _, employee := range employees {
@@ -224,12 +97,14 @@ func main() {
 	if err != nil {
 		fmt.Println("Error: ", err)
 	}
-	fmt.Println("Records: ", records)
+	for _, record := range records {
+		fmt.Println("Record: ", string(record))
+	}
 
 	allUsers := make([]User, 0)
 	for _, record := range records {
 		var user User
-		err := json.Unmarshal([]byte(record), &user)
+		err := json.Unmarshal(record, &user)
 		if err != nil {
 			fmt.Println("Error: ", err)
 		}
@@ -240,4 +115,11 @@ func main() {
 	if err := db.Delete("users", "John"); err != nil {
 		fmt.Println("Error: ", err)
 	}
+
+	// Demonstrate the rejection path explicitly: Mallory tries to claim
+	// Paul's contact and OnBeforeWrite turns her down.
+	mallory := User{"Mallory", "29", "214", "Initech", Address{"Street 7", "City 7", "Country 7", "123456"}}
+	if err := db.Write("users", mallory.Name, &mallory); err != nil {
+		fmt.Println("Expected error writing a duplicate contact: ", err)
+	}
 }
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/bakhtybayevn/simple-database/simpledb"
+)
+
+// routeCollection dispatches /v1/{collection}[/{resource}|/_query|/_watch]
+// to the right handler based on method and path shape.
+func (s *Server) routeCollection(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/"), "/"), "/")
+	if len(parts) == 0 || parts[0] == "" {
+		writeError(w, http.StatusNotFound, "collection is required")
+		return
+	}
+	collection := parts[0]
+
+	switch {
+	case len(parts) == 1:
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "collection listing only supports GET")
+			return
+		}
+		s.handleList(w, r, collection)
+
+	case len(parts) == 2 && parts[1] == "_query":
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "_query only supports POST")
+			return
+		}
+		s.handleQuery(w, r, collection)
+
+	case len(parts) == 2 && parts[1] == "_watch":
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "_watch only supports GET")
+			return
+		}
+		s.handleWatch(w, r, collection)
+
+	case len(parts) == 2:
+		s.handleResource(w, r, collection, parts[1])
+
+	default:
+		writeError(w, http.StatusNotFound, "unrecognized path")
+	}
+}
+
+func (s *Server) handleResource(w http.ResponseWriter, r *http.Request, collection, resource string) {
+	switch r.Method {
+	case http.MethodGet:
+		var v map[string]interface{}
+		if err := s.driver.Read(collection, resource, &v); err != nil {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, v)
+
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		var v map[string]interface{}
+		if err := json.Unmarshal(body, &v); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+			return
+		}
+		// The body is always JSON on the wire; Write re-encodes v with the
+		// driver's configured codec before it touches disk.
+		if err := s.driver.Write(collection, resource, v); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case http.MethodDelete:
+		if err := s.driver.Delete(collection, resource); err != nil {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "unsupported method")
+	}
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request, collection string) {
+	records, err := s.driver.ReadAll(collection)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	out, err := decodeRecords(s.driver, records)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// queryRequest is the body accepted by POST /v1/{collection}/_query.
+type queryRequest struct {
+	Eq     map[string]interface{} `json:"eq"`
+	Sort   string                 `json:"sort"`
+	Desc   bool                   `json:"desc"`
+	Limit  int                    `json:"limit"`
+	Offset int                    `json:"offset"`
+}
+
+func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request, collection string) {
+	var req queryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+
+	q := s.driver.Query(collection)
+	for field, value := range req.Eq {
+		q = q.Eq(field, value)
+	}
+	if req.Sort != "" {
+		q = q.Sort(req.Sort, req.Desc)
+	}
+	if req.Limit > 0 {
+		q = q.Limit(req.Limit)
+	}
+	if req.Offset > 0 {
+		q = q.Offset(req.Offset)
+	}
+
+	var out []map[string]interface{}
+	if err := q.Find(&out); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// decodeRecords decodes raw, codec-encoded record bytes (as returned by
+// Driver.ReadAll) into generic values, so the wire format is always JSON
+// regardless of how the driver stores records on disk.
+func decodeRecords(driver *simpledb.Driver, records [][]byte) ([]map[string]interface{}, error) {
+	out := make([]map[string]interface{}, len(records))
+	for i, r := range records {
+		if err := driver.Decode(r, &out[i]); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
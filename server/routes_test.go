@@ -0,0 +1,171 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bakhtybayevn/simple-database/simpledb"
+)
+
+func newTestServer(t *testing.T, codecName, token string) *Server {
+	t.Helper()
+	driver, err := simpledb.New(t.TempDir(), &simpledb.Options{CodecName: codecName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(driver, token)
+}
+
+func doRequest(s *Server, method, path string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandleResourcePutGetDelete(t *testing.T) {
+	s := newTestServer(t, "json", "")
+
+	rec := doRequest(s, http.MethodPut, "/v1/users/alice", []byte(`{"name":"alice"}`))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("PUT: expected 204, got %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = doRequest(s, http.MethodGet, "/v1/users/alice", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var got map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["name"] != "alice" {
+		t.Fatalf("unexpected body: %+v", got)
+	}
+
+	rec = doRequest(s, http.MethodDelete, "/v1/users/alice", nil)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE: expected 204, got %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = doRequest(s, http.MethodGet, "/v1/users/alice", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("GET after delete: expected 404, got %d", rec.Code)
+	}
+}
+
+func TestHandleResourcePutRejectsInvalidJSON(t *testing.T) {
+	s := newTestServer(t, "json", "")
+	rec := doRequest(s, http.MethodPut, "/v1/users/alice", []byte(`not json`))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestHandleList(t *testing.T) {
+	s := newTestServer(t, "json", "")
+	doRequest(s, http.MethodPut, "/v1/users/alice", []byte(`{"name":"alice"}`))
+	doRequest(s, http.MethodPut, "/v1/users/bob", []byte(`{"name":"bob"}`))
+
+	rec := doRequest(s, http.MethodGet, "/v1/users", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var out []map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(out))
+	}
+}
+
+func TestHandleQuery(t *testing.T) {
+	s := newTestServer(t, "json", "")
+	doRequest(s, http.MethodPut, "/v1/users/alice", []byte(`{"name":"alice","age":30}`))
+	doRequest(s, http.MethodPut, "/v1/users/bob", []byte(`{"name":"bob","age":25}`))
+
+	rec := doRequest(s, http.MethodPost, "/v1/users/_query", []byte(`{"eq":{"name":"bob"}}`))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var out []map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out[0]["name"] != "bob" {
+		t.Fatalf("unexpected query result: %+v", out)
+	}
+}
+
+// TestRoutesHonorBSONCodec reproduces the maintainer's repro: every route
+// must work end-to-end even when the driver is configured with a non-JSON
+// codec, since the wire format is always JSON regardless of storage.
+func TestRoutesHonorBSONCodec(t *testing.T) {
+	s := newTestServer(t, "bson", "")
+
+	rec := doRequest(s, http.MethodPut, "/v1/users/alice", []byte(`{"name":"alice"}`))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("PUT under bson: expected 204, got %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = doRequest(s, http.MethodGet, "/v1/users/alice", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET under bson: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var got map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["name"] != "alice" {
+		t.Fatalf("unexpected body: %+v", got)
+	}
+
+	rec = doRequest(s, http.MethodGet, "/v1/users", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list under bson: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = doRequest(s, http.MethodPost, "/v1/users/_query", []byte(`{"eq":{"name":"alice"}}`))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("query under bson: expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var out []map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 {
+		t.Fatalf("expected 1 query match under bson, got %d", len(out))
+	}
+}
+
+func TestAuthRejectsMissingOrWrongToken(t *testing.T) {
+	s := newTestServer(t, "json", "secret")
+	if err := s.driver.Write("users", "alice", map[string]string{"name": "alice"}); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := doRequest(s, http.MethodGet, "/v1/users", nil)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 with no token, got %d", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/users", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	rec2 := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec2, req)
+	if rec2.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 with wrong token, got %d", rec2.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/users", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec3 := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec3, req)
+	if rec3.Code != http.StatusOK {
+		t.Fatalf("expected 200 with correct token, got %d: %s", rec3.Code, rec3.Body)
+	}
+}
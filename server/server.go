@@ -0,0 +1,95 @@
+// Package server exposes a simpledb.Driver over HTTP as a small REST API,
+// used by the `simpledb serve` CLI subcommand.
+package server
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/bakhtybayevn/simple-database/simpledb"
+)
+
+// Config is the on-disk shape of the -config file passed to `serve`.
+type Config struct {
+	Dir       string `json:"dir"`
+	Addr      string `json:"addr"`
+	CodecName string `json:"codec"`
+	Token     string `json:"token"`
+}
+
+// Server wraps a Driver with HTTP handlers for the /v1 API.
+type Server struct {
+	driver *simpledb.Driver
+	token  string
+}
+
+// New builds a Server around an already-constructed Driver. token is an
+// optional bearer token required on every request; an empty token disables
+// auth.
+func New(driver *simpledb.Driver, token string) *Server {
+	return &Server{driver: driver, token: token}
+}
+
+// Handler returns the http.Handler implementing the /v1 API, ready to be
+// mounted directly or wrapped further by the caller.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/", s.auth(s.routeCollection))
+	return mux
+}
+
+// Run parses CLI flags for the `serve` subcommand, builds a Driver and
+// Server, and blocks serving HTTP until the process is killed.
+func Run(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	configPath := fs.String("config", "", "path to a JSON config file (dir, addr, codec, token)")
+	dir := fs.String("dir", "./", "database directory")
+	addr := fs.String("addr", ":8080", "address to listen on")
+	codecName := fs.String("codec", "json", "codec to store records with (json, bson, ...)")
+	token := fs.String("token", "", "bearer token required on every request; empty disables auth")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg := Config{Dir: *dir, Addr: *addr, CodecName: *codecName, Token: *token}
+	if *configPath != "" {
+		b, err := os.ReadFile(*configPath)
+		if err != nil {
+			return fmt.Errorf("reading config file: %w", err)
+		}
+		if err := json.Unmarshal(b, &cfg); err != nil {
+			return fmt.Errorf("parsing config file: %w", err)
+		}
+	}
+
+	driver, err := simpledb.New(cfg.Dir, &simpledb.Options{CodecName: cfg.CodecName})
+	if err != nil {
+		return fmt.Errorf("opening database at '%s': %w", cfg.Dir, err)
+	}
+
+	srv := New(driver, cfg.Token)
+	fmt.Printf("simpledb serve: listening on %s, database '%s'\n", cfg.Addr, cfg.Dir)
+	return http.ListenAndServe(cfg.Addr, srv.Handler())
+}
+
+func (s *Server) auth(next http.HandlerFunc) http.HandlerFunc {
+	if s.token == "" {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+s.token {
+			writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// handleWatch streams insert/update/delete events for a collection as
+// Server-Sent Events, driven by an fsnotify watch on the collection
+// directory, so clients don't have to poll.
+func (s *Server) handleWatch(w http.ResponseWriter, r *http.Request, collection string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	dir := s.driver.CollectionDir(collection)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(dir); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ext := s.driver.CodecExt()
+	ctx := r.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Ext(event.Name) != ext {
+				continue
+			}
+			resource := strings.TrimSuffix(filepath.Base(event.Name), ext)
+			fmt.Fprintf(w, "event: %s\ndata: {\"resource\":%q}\n\n", watchEventName(event.Op), resource)
+			flusher.Flush()
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(w, "event: error\ndata: %q\n\n", err.Error())
+			flusher.Flush()
+		}
+	}
+}
+
+func watchEventName(op fsnotify.Op) string {
+	switch {
+	case op&fsnotify.Create != 0, op&fsnotify.Write != 0:
+		return "upsert"
+	case op&fsnotify.Remove != 0:
+		return "delete"
+	case op&fsnotify.Rename != 0:
+		return "upsert"
+	default:
+		return "change"
+	}
+}
@@ -0,0 +1,70 @@
+package simpledb
+
+import (
+	"encoding/json"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Codec marshals and unmarshals records for a collection and reports the
+// file extension records should be stored under.
+type Codec interface {
+	Ext() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec is the default Codec, stored as indented JSON for readability.
+type JSONCodec struct{}
+
+func (JSONCodec) Ext() string { return ".json" }
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	b, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// BSONCodec stores records as BSON documents, useful when records need to
+// round-trip through Mongo tooling or when binary storage is preferred.
+type BSONCodec struct{}
+
+func (BSONCodec) Ext() string { return ".bson" }
+
+func (BSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return bson.Marshal(v)
+}
+
+func (BSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return bson.Unmarshal(data, v)
+}
+
+// codecRegistry holds codecs registered by name, so third-party codecs
+// (YAML, CBOR, MsgPack, gob, ...) can be plugged in without touching the
+// core driver.
+var codecRegistry = map[string]func() Codec{
+	"json": func() Codec { return JSONCodec{} },
+	"bson": func() Codec { return BSONCodec{} },
+}
+
+// RegisterCodec makes a named Codec available to Options.CodecName. It is
+// meant to be called from an init() function by packages that add support
+// for additional encodings.
+func RegisterCodec(name string, factory func() Codec) {
+	codecRegistry[name] = factory
+}
+
+// CodecByName looks up a codec previously registered with RegisterCodec.
+func CodecByName(name string) (Codec, bool) {
+	factory, ok := codecRegistry[name]
+	if !ok {
+		return nil, false
+	}
+	return factory(), true
+}
@@ -0,0 +1,98 @@
+package simpledb
+
+import "testing"
+
+type codecTestRecord struct {
+	Name    string `json:"name" bson:"name"`
+	Country string `json:"country" bson:"country"`
+}
+
+func TestJSONCodecRoundTrip(t *testing.T) {
+	c := JSONCodec{}
+	b, err := c.Marshal(codecTestRecord{Name: "alice", Country: "India"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out codecTestRecord
+	if err := c.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != "alice" || out.Country != "India" {
+		t.Fatalf("unexpected round-trip: %+v", out)
+	}
+	if c.Ext() != ".json" {
+		t.Fatalf("expected .json extension, got %q", c.Ext())
+	}
+}
+
+func TestBSONCodecRoundTrip(t *testing.T) {
+	c := BSONCodec{}
+	b, err := c.Marshal(codecTestRecord{Name: "bob", Country: "US"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out codecTestRecord
+	if err := c.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != "bob" || out.Country != "US" {
+		t.Fatalf("unexpected round-trip: %+v", out)
+	}
+	if c.Ext() != ".bson" {
+		t.Fatalf("expected .bson extension, got %q", c.Ext())
+	}
+}
+
+func TestCodecByName(t *testing.T) {
+	if _, ok := CodecByName("nonexistent"); ok {
+		t.Fatal("expected no codec registered under 'nonexistent'")
+	}
+
+	codec, ok := CodecByName("bson")
+	if !ok {
+		t.Fatal("expected 'bson' to be registered")
+	}
+	if _, ok := codec.(BSONCodec); !ok {
+		t.Fatalf("expected BSONCodec, got %T", codec)
+	}
+}
+
+func TestRegisterCodec(t *testing.T) {
+	RegisterCodec("codec-test-noop", func() Codec { return JSONCodec{} })
+	codec, ok := CodecByName("codec-test-noop")
+	if !ok {
+		t.Fatal("expected newly registered codec to be found")
+	}
+	if _, ok := codec.(JSONCodec); !ok {
+		t.Fatalf("expected JSONCodec, got %T", codec)
+	}
+}
+
+func TestDriverUsesConfiguredCodec(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, &Options{CodecName: "bson"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write("users", "alice", codecTestRecord{Name: "alice", Country: "India"}); err != nil {
+		t.Fatal(err)
+	}
+	if d.CodecExt() != ".bson" {
+		t.Fatalf("expected .bson extension, got %q", d.CodecExt())
+	}
+
+	var out codecTestRecord
+	if err := d.Read("users", "alice", &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != "alice" {
+		t.Fatalf("unexpected record: %+v", out)
+	}
+}
+
+func TestNewRejectsUnknownCodecName(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := New(dir, &Options{CodecName: "does-not-exist"}); err == nil {
+		t.Fatal("expected an error for an unregistered codec name")
+	}
+}
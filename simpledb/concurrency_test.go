@@ -0,0 +1,90 @@
+package simpledb
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// TestConcurrentWriters hammers the same collection with many goroutines
+// writing distinct resources and others reading them back, to catch races
+// between the per-collection RWMutex and the per-resource shard locks.
+// Run with -race to be useful.
+func TestConcurrentWriters(t *testing.T) {
+	dir := t.TempDir()
+
+	d, err := New(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 100
+	var wg sync.WaitGroup
+	wg.Add(n * 2)
+
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("user-%d", i)
+			if err := d.Write("users", name, map[string]int{"id": i}); err != nil {
+				t.Errorf("write %s: %v", name, err)
+			}
+		}(i)
+	}
+
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("user-%d", i)
+			var out map[string]int
+			// The write may not have landed yet; a not-found error is fine,
+			// corruption or a race detector failure is not.
+			_ = d.Read("users", name, &out)
+		}(i)
+	}
+
+	wg.Wait()
+
+	records, err := d.ReadAll("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != n {
+		t.Fatalf("expected %d records, got %d", n, len(records))
+	}
+}
+
+// TestConcurrentWritersWAL is TestConcurrentWriters under DurabilityWAL,
+// where every writer in the collection shares the single `.wal` file. It
+// guards against the WAL mutex being dropped or scoped too narrowly and
+// letting one writer's frame clobber another's before it's durable.
+func TestConcurrentWritersWAL(t *testing.T) {
+	dir := t.TempDir()
+
+	d, err := New(dir, &Options{Durability: DurabilityWAL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 64
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("user-%d", i)
+			if err := d.Write("users", name, map[string]int{"id": i}); err != nil {
+				t.Errorf("write %s: %v", name, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	records, err := d.ReadAll("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != n {
+		t.Fatalf("expected %d records, got %d", n, len(records))
+	}
+}
@@ -0,0 +1,345 @@
+// Package simpledb is a tiny JSON-document database backed by the
+// filesystem: one directory per collection, one file per resource.
+package simpledb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/jcelliott/lumber"
+)
+
+const Version = "1.0.0"
+
+type Logger interface {
+	Fatal(string, ...interface{})
+	Error(string, ...interface{})
+	Warn(string, ...interface{})
+	Info(string, ...interface{})
+	Debug(string, ...interface{})
+	Trace(string, ...interface{})
+}
+
+type Driver struct {
+	mu         sync.Mutex
+	caches     map[string]*sync.RWMutex
+	walMu      map[string]*sync.Mutex
+	shards     resourceShards
+	dir        string
+	log        Logger
+	codec      Codec
+	indexes    indexCache
+	durability Durability
+	schemas    schemaRegistry
+
+	onBeforeWrite  func(collection, resource string, payload []byte) error
+	onAfterWrite   func(collection, resource string, payload []byte)
+	onBeforeDelete func(collection, resource string) error
+}
+
+type Options struct {
+	Logger
+
+	// Codec controls how records are marshaled to disk and which file
+	// extension they're stored under. Defaults to JSONCodec.
+	Codec Codec
+
+	// CodecName looks up a codec registered via RegisterCodec. It is
+	// ignored if Codec is set explicitly.
+	CodecName string
+
+	// Durability controls how hard Write/Delete try to survive a crash.
+	// Defaults to DurabilityNone, matching the original tmp+rename
+	// behaviour.
+	Durability Durability
+
+	// OnBeforeWrite runs before a record is persisted, with the payload
+	// already marshaled and schema-validated. Returning an error aborts
+	// the write. Useful for invariants RegisterSchema can't express, e.g.
+	// uniqueness of a field across a collection.
+	OnBeforeWrite func(collection, resource string, payload []byte) error
+
+	// OnAfterWrite runs once a record has been durably written.
+	OnAfterWrite func(collection, resource string, payload []byte)
+
+	// OnBeforeDelete runs before a record is removed. Returning an error
+	// aborts the delete.
+	OnBeforeDelete func(collection, resource string) error
+}
+
+func New(dir string, options *Options) (*Driver, error) {
+	dir = filepath.Clean(dir)
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+
+	if opts.Logger == nil {
+		opts.Logger = lumber.NewConsoleLogger(lumber.INFO)
+	}
+
+	if opts.Codec == nil && opts.CodecName != "" {
+		codec, ok := CodecByName(opts.CodecName)
+		if !ok {
+			return nil, fmt.Errorf("no codec registered under name '%s'", opts.CodecName)
+		}
+		opts.Codec = codec
+	}
+	if opts.Codec == nil {
+		opts.Codec = JSONCodec{}
+	}
+
+	driver := &Driver{
+		dir:            dir,
+		log:            opts.Logger,
+		codec:          opts.Codec,
+		durability:     opts.Durability,
+		caches:         make(map[string]*sync.RWMutex),
+		walMu:          make(map[string]*sync.Mutex),
+		onBeforeWrite:  opts.OnBeforeWrite,
+		onAfterWrite:   opts.OnAfterWrite,
+		onBeforeDelete: opts.OnBeforeDelete,
+	}
+
+	if _, err := os.Stat(dir); err != nil {
+		opts.Logger.Debug("Using '%s' (database already exists)\n", dir)
+	} else {
+		opts.Logger.Debug("Creating the database at '%s'...\n", dir)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := driver.Recover(); err != nil {
+		return nil, err
+	}
+	if err := driver.loadSchemas(); err != nil {
+		return nil, err
+	}
+	return driver, nil
+}
+
+func (d *Driver) Write(collection, resource string, v interface{}) error {
+	if collection == "" || resource == "" {
+		return fmt.Errorf("collection and resource must not be empty when writing a record")
+	}
+
+	collMu := d.getOrCreateMutex(collection)
+	collMu.RLock()
+	defer collMu.RUnlock()
+
+	shard := d.shards.get(collection, resource)
+	shard.Lock()
+	defer shard.Unlock()
+
+	dir := filepath.Join(d.dir, collection)
+	fnlPath := filepath.Join(dir, resource+d.codec.Ext())
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	b, err := d.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	if err := d.validate(collection, resource, b); err != nil {
+		return err
+	}
+	if d.onBeforeWrite != nil {
+		if err := d.onBeforeWrite(collection, resource, b); err != nil {
+			return err
+		}
+	}
+
+	if d.durability == DurabilityWAL {
+		// The WAL file is shared by the whole collection, not sharded per
+		// resource, so it needs real exclusivity: hold it across the
+		// entire writeWAL-through-clearWAL sequence, not just the append.
+		walMu := d.getOrCreateWALMutex(collection)
+		walMu.Lock()
+		defer walMu.Unlock()
+
+		sum := sha256.Sum256(b)
+		if err := writeWAL(dir, walFrame{Op: "write", Resource: resource, Checksum: hex.EncodeToString(sum[:]), Payload: b}); err != nil {
+			return err
+		}
+	}
+
+	if d.durability >= DurabilityFsync {
+		if err := writeDurable(fnlPath, b); err != nil {
+			return err
+		}
+	} else {
+		if err := ioutil.WriteFile(fnlPath+".tmp", b, 0644); err != nil {
+			return err
+		}
+		if err := os.Rename(fnlPath+".tmp", fnlPath); err != nil {
+			return err
+		}
+	}
+
+	if d.durability == DurabilityWAL {
+		if err := clearWAL(dir); err != nil {
+			return err
+		}
+	}
+
+	d.invalidateIndexes(collection)
+	if d.onAfterWrite != nil {
+		d.onAfterWrite(collection, resource, b)
+	}
+	return nil
+}
+
+func (d *Driver) Read(collection, resource string, v interface{}) error {
+	if collection == "" || resource == "" {
+		return fmt.Errorf("collection and resource must not be empty when reading a record")
+	}
+
+	collMu := d.getOrCreateMutex(collection)
+	collMu.RLock()
+	defer collMu.RUnlock()
+
+	shard := d.shards.get(collection, resource)
+	shard.RLock()
+	defer shard.RUnlock()
+
+	recordPath := filepath.Join(d.dir, collection, resource)
+	if _, err := d.stat(recordPath); err != nil {
+		return err
+	}
+
+	b, err := ioutil.ReadFile(recordPath + d.codec.Ext())
+	if err != nil {
+		return err
+	}
+
+	return d.codec.Unmarshal(b, v)
+}
+
+func (d *Driver) ReadAll(collection string) ([][]byte, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("collection must not be empty when reading all records")
+	}
+
+	collMu := d.getOrCreateMutex(collection)
+	collMu.RLock()
+	defer collMu.RUnlock()
+
+	dir := filepath.Join(d.dir, collection)
+	if _, err := d.stat(dir); err != nil {
+		return nil, err
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([][]byte, 0, len(files))
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != d.codec.Ext() {
+			continue
+		}
+
+		b, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, b)
+	}
+
+	return records, nil
+}
+
+func (d *Driver) Delete(collection, resource string) error {
+	if collection == "" || resource == "" {
+		return fmt.Errorf("collection and resource must not be empty when deleting a record")
+	}
+
+	path := filepath.Join(collection, resource)
+	collMu := d.getOrCreateMutex(collection)
+	collMu.RLock()
+	defer collMu.RUnlock()
+
+	shard := d.shards.get(collection, resource)
+	shard.Lock()
+	defer shard.Unlock()
+
+	if d.onBeforeDelete != nil {
+		if err := d.onBeforeDelete(collection, resource); err != nil {
+			return err
+		}
+	}
+
+	dir := filepath.Join(d.dir, path)
+
+	collectionDir := filepath.Join(d.dir, collection)
+
+	switch fi, err := d.stat(dir); {
+	case fi == nil, err != nil:
+		return fmt.Errorf("unable to delete '%s' because it does not exist", path)
+	case fi.Mode().IsDir():
+		err := os.RemoveAll(dir)
+		d.invalidateIndexes(collection)
+		return err
+	case fi.Mode().IsRegular():
+		if d.durability == DurabilityWAL {
+			walMu := d.getOrCreateWALMutex(collection)
+			walMu.Lock()
+			defer walMu.Unlock()
+
+			if err := writeWAL(collectionDir, walFrame{Op: "delete", Resource: resource}); err != nil {
+				return err
+			}
+		}
+		err := os.RemoveAll(dir + d.codec.Ext())
+		if err == nil && d.durability >= DurabilityFsync {
+			err = syncDir(collectionDir)
+		}
+		if err == nil && d.durability == DurabilityWAL {
+			err = clearWAL(collectionDir)
+		}
+		d.invalidateIndexes(collection)
+		return err
+	}
+
+	return nil
+}
+
+// stat looks up path as-is, falling back to path plus the driver's codec
+// extension so callers can pass either a bare resource path or a directory.
+func (d *Driver) stat(path string) (fi os.FileInfo, err error) {
+	if fi, err = os.Stat(path); os.IsNotExist(err) {
+		fi, err = os.Stat(path + d.codec.Ext())
+	}
+	return
+}
+
+// CollectionDir returns the on-disk directory backing collection, for
+// callers (such as the server package's change-feed watcher) that need to
+// observe the filesystem directly rather than through Read/Write.
+func (d *Driver) CollectionDir(collection string) string {
+	return filepath.Join(d.dir, collection)
+}
+
+// CodecExt returns the file extension records in this database are stored
+// under, e.g. ".json" or ".bson".
+func (d *Driver) CodecExt() string {
+	return d.codec.Ext()
+}
+
+// Decode unmarshals data (as returned by ReadAll) into v using this driver's
+// configured codec, for callers that read raw record bytes directly instead
+// of through Read.
+func (d *Driver) Decode(data []byte, v interface{}) error {
+	return d.codec.Unmarshal(data, v)
+}
@@ -0,0 +1,58 @@
+package simpledb
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// shardCount bounds the pool of per-resource locks so the driver doesn't
+// grow one lock per resource ever written. Collisions just mean two
+// unrelated resources occasionally contend, which is an acceptable
+// trade-off for a bounded, allocation-free pool.
+const shardCount = 64
+
+// resourceShards is a fixed-size pool of RWMutexes shared by every
+// collection, indexed by a hash of "collection/resource". It gives
+// concurrent writers to different resources in the same collection
+// independent locks, instead of serializing on one per-collection mutex.
+type resourceShards [shardCount]sync.RWMutex
+
+func (s *resourceShards) get(collection, resource string) *sync.RWMutex {
+	h := fnv.New32a()
+	h.Write([]byte(collection))
+	h.Write([]byte{'/'})
+	h.Write([]byte(resource))
+	return &s[h.Sum32()%shardCount]
+}
+
+// getOrCreateMutex returns the per-collection RWMutex, creating it on first
+// use. Readers (Read, ReadAll) take RLock; Write and Delete also take
+// RLock, relying on the resource shard lock for mutual exclusion, so
+// unrelated writes within the same collection don't block each other.
+func (d *Driver) getOrCreateMutex(collection string) *sync.RWMutex {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	m, ok := d.caches[collection]
+	if !ok {
+		m = &sync.RWMutex{}
+		d.caches[collection] = m
+	}
+	return m
+}
+
+// getOrCreateWALMutex returns the per-collection mutex guarding the single
+// shared `.wal` file, creating it on first use. Unlike the resource shard
+// locks, this one must give one collection's WAL frame real exclusivity:
+// two concurrent writers to different resources in the same collection can
+// still race to write/clear the one WAL file, so DurabilityWAL holds this
+// for the whole writeWAL-through-clearWAL sequence.
+func (d *Driver) getOrCreateWALMutex(collection string) *sync.Mutex {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	m, ok := d.walMu[collection]
+	if !ok {
+		m = &sync.Mutex{}
+		d.walMu[collection] = m
+	}
+	return m
+}
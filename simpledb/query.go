@@ -0,0 +1,241 @@
+package simpledb
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Query builds up a filtered read against a single collection. It is
+// constructed via Driver.Query and executed with Find.
+type Query struct {
+	driver     *Driver
+	collection string
+	predicates []func(json.RawMessage) bool
+
+	indexField string
+	indexValue interface{}
+
+	sortField string
+	sortDesc  bool
+	limit     int
+	offset    int
+}
+
+// Query starts building a filtered read against collection.
+func (d *Driver) Query(collection string) *Query {
+	return &Query{driver: d, collection: collection}
+}
+
+// Where adds a predicate evaluated against each record's raw encoded form.
+func (q *Query) Where(fn func(raw json.RawMessage) bool) *Query {
+	q.predicates = append(q.predicates, fn)
+	return q
+}
+
+// Eq filters records whose field at the dotted path equals value, e.g.
+// Eq("address.country", "India"). When this is the first call on the
+// query it is served from a lazily built secondary index instead of a
+// full collection scan.
+func (q *Query) Eq(path string, value interface{}) *Query {
+	if q.indexField == "" && len(q.predicates) == 0 {
+		q.indexField = path
+		q.indexValue = value
+	}
+	return q.Where(func(raw json.RawMessage) bool {
+		v, ok := lookupPath(q.driver.codec, raw, path)
+		if !ok {
+			return false
+		}
+		return fmt.Sprint(v) == fmt.Sprint(value)
+	})
+}
+
+// Sort orders results by the field at path, ascending unless desc is true.
+// Sorting is applied after all predicates and before Limit/Offset.
+func (q *Query) Sort(path string, desc bool) *Query {
+	q.sortField = path
+	q.sortDesc = desc
+	return q
+}
+
+// Limit caps the number of records returned by Find.
+func (q *Query) Limit(n int) *Query {
+	q.limit = n
+	return q
+}
+
+// Offset skips the first n matching records before Limit is applied.
+func (q *Query) Offset(n int) *Query {
+	q.offset = n
+	return q
+}
+
+// Find runs the query and decodes matching records into out, which must be
+// a pointer to a slice.
+func (q *Query) Find(out interface{}) error {
+	outVal := reflect.ValueOf(out)
+	if outVal.Kind() != reflect.Ptr || outVal.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("simpledb: Find requires a pointer to a slice, got %T", out)
+	}
+	sliceVal := outVal.Elem()
+	elemType := sliceVal.Type().Elem()
+
+	raws, err := q.candidates()
+	if err != nil {
+		return err
+	}
+
+	matches := raws[:0]
+	for _, raw := range raws {
+		keep := true
+		for _, pred := range q.predicates {
+			if !pred(raw) {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			matches = append(matches, raw)
+		}
+	}
+
+	if q.sortField != "" {
+		sort.SliceStable(matches, func(i, j int) bool {
+			vi, _ := lookupPath(q.driver.codec, matches[i], q.sortField)
+			vj, _ := lookupPath(q.driver.codec, matches[j], q.sortField)
+			less := fmt.Sprint(vi) < fmt.Sprint(vj)
+			if q.sortDesc {
+				return !less
+			}
+			return less
+		})
+	}
+
+	if q.offset > 0 {
+		if q.offset >= len(matches) {
+			matches = nil
+		} else {
+			matches = matches[q.offset:]
+		}
+	}
+	if q.limit > 0 && q.limit < len(matches) {
+		matches = matches[:q.limit]
+	}
+
+	result := reflect.MakeSlice(sliceVal.Type(), 0, len(matches))
+	for _, raw := range matches {
+		elem := reflect.New(elemType)
+		if err := q.driver.codec.Unmarshal(raw, elem.Interface()); err != nil {
+			return err
+		}
+		result = reflect.Append(result, elem.Elem())
+	}
+	sliceVal.Set(result)
+	return nil
+}
+
+// candidates returns the raw records to run predicates against, using the
+// secondary index when Eq was the first filter applied.
+func (q *Query) candidates() ([]json.RawMessage, error) {
+	if q.indexField != "" {
+		idx, err := q.driver.fieldIndex(q.collection, q.indexField)
+		if err != nil {
+			return nil, err
+		}
+		// Find filters its result in place, so it must not be handed the
+		// index's own backing array: that would corrupt the cached index for
+		// every later lookup. Return a defensive copy instead.
+		bucket := idx[fmt.Sprint(q.indexValue)]
+		raws := make([]json.RawMessage, len(bucket))
+		copy(raws, bucket)
+		return raws, nil
+	}
+
+	records, err := q.driver.ReadAll(q.collection)
+	if err != nil {
+		return nil, err
+	}
+	raws := make([]json.RawMessage, len(records))
+	for i, r := range records {
+		raws[i] = r
+	}
+	return raws, nil
+}
+
+// lookupPath walks a dotted field path (e.g. "address.country") through a
+// record decoded with the driver's codec, so Eq/Sort/the secondary index
+// work against any codec, not just JSON.
+func lookupPath(codec Codec, raw []byte, path string) (interface{}, bool) {
+	var m map[string]interface{}
+	if err := codec.Unmarshal(raw, &m); err != nil {
+		return nil, false
+	}
+	var cur interface{} = m
+	for _, part := range strings.Split(path, ".") {
+		mm, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		cur, ok = mm[part]
+		if !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// index is a secondary lookup from a field's string value to the raw
+// records sharing that value, built lazily per (collection, field).
+type index map[string][]json.RawMessage
+
+type indexCache struct {
+	mu  sync.Mutex
+	byC map[string]map[string]index
+}
+
+// fieldIndex returns the index for (collection, field), building it from a
+// full scan the first time it's requested.
+func (d *Driver) fieldIndex(collection, field string) (index, error) {
+	d.indexes.mu.Lock()
+	defer d.indexes.mu.Unlock()
+
+	if d.indexes.byC == nil {
+		d.indexes.byC = make(map[string]map[string]index)
+	}
+	fields, ok := d.indexes.byC[collection]
+	if !ok {
+		fields = make(map[string]index)
+		d.indexes.byC[collection] = fields
+	}
+	if idx, ok := fields[field]; ok {
+		return idx, nil
+	}
+
+	records, err := d.ReadAll(collection)
+	if err != nil {
+		return nil, err
+	}
+	idx := make(index)
+	for _, raw := range records {
+		v, ok := lookupPath(d.codec, raw, field)
+		if !ok {
+			continue
+		}
+		key := fmt.Sprint(v)
+		idx[key] = append(idx[key], raw)
+	}
+	fields[field] = idx
+	return idx, nil
+}
+
+// invalidateIndexes drops every cached index for collection. Called on
+// Write and Delete so stale index entries are never served.
+func (d *Driver) invalidateIndexes(collection string) {
+	d.indexes.mu.Lock()
+	defer d.indexes.mu.Unlock()
+	delete(d.indexes.byC, collection)
+}
@@ -0,0 +1,181 @@
+package simpledb
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type queryTestUser struct {
+	Name    string `json:"name"`
+	Age     int    `json:"age"`
+	Address struct {
+		Country string `json:"country"`
+	} `json:"address"`
+}
+
+func seedQueryUsers(t *testing.T, d *Driver) {
+	t.Helper()
+	users := []struct {
+		name    string
+		age     int
+		country string
+	}{
+		{"alice", 30, "India"},
+		{"bob", 25, "US"},
+		{"carol", 40, "India"},
+	}
+	for _, u := range users {
+		var rec queryTestUser
+		rec.Name = u.name
+		rec.Age = u.age
+		rec.Address.Country = u.country
+		if err := d.Write("users", u.name, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestQueryEqUsesIndex(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seedQueryUsers(t, d)
+
+	var out []queryTestUser
+	if err := d.Query("users").Eq("address.country", "India").Find(&out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 {
+		t.Fatalf("expected 2 matches, got %d: %+v", len(out), out)
+	}
+}
+
+func TestQuerySortLimitOffset(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seedQueryUsers(t, d)
+
+	var out []queryTestUser
+	if err := d.Query("users").Sort("age", false).Find(&out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 3 || out[0].Name != "bob" || out[2].Name != "carol" {
+		t.Fatalf("expected ascending age order bob,alice,carol, got %+v", out)
+	}
+
+	out = nil
+	if err := d.Query("users").Sort("age", true).Limit(1).Find(&out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out[0].Name != "carol" {
+		t.Fatalf("expected carol as the oldest, got %+v", out)
+	}
+
+	out = nil
+	if err := d.Query("users").Sort("age", false).Offset(1).Find(&out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 || out[0].Name != "alice" {
+		t.Fatalf("expected offset to skip bob, got %+v", out)
+	}
+}
+
+func TestQueryIndexInvalidatedOnWrite(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seedQueryUsers(t, d)
+
+	var out []queryTestUser
+	if err := d.Query("users").Eq("address.country", "India").Find(&out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 {
+		t.Fatalf("expected 2 matches before write, got %d", len(out))
+	}
+
+	var dave queryTestUser
+	dave.Name = "dave"
+	dave.Age = 50
+	dave.Address.Country = "India"
+	if err := d.Write("users", "dave", dave); err != nil {
+		t.Fatal(err)
+	}
+
+	out = nil
+	if err := d.Query("users").Eq("address.country", "India").Find(&out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 3 {
+		t.Fatalf("expected index to reflect the new write, got %d matches", len(out))
+	}
+}
+
+func TestQueryEqAgainstBSONCollection(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, &Options{CodecName: "bson"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seedQueryUsers(t, d)
+
+	var out []queryTestUser
+	if err := d.Query("users").Eq("address.country", "US").Find(&out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out[0].Name != "bob" {
+		t.Fatalf("expected bob, got %+v", out)
+	}
+}
+
+// TestQueryDoesNotCorruptIndexWhenFilteringFurther guards against Find
+// filtering its candidates in place over the secondary index's own backing
+// array. Combining an indexed Eq with a second predicate that rejects at
+// least one bucket member must not affect what a later, unrelated Eq on the
+// same field sees.
+func TestQueryDoesNotCorruptIndexWhenFilteringFurther(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seedQueryUsers(t, d)
+
+	var dave queryTestUser
+	dave.Name = "dave"
+	dave.Age = 50
+	dave.Address.Country = "India"
+	if err := d.Write("users", "dave", dave); err != nil {
+		t.Fatal(err)
+	}
+
+	var filtered []queryTestUser
+	err = d.Query("users").Eq("address.country", "India").Where(func(raw json.RawMessage) bool {
+		var u queryTestUser
+		if err := d.codec.Unmarshal(raw, &u); err != nil {
+			t.Fatal(err)
+		}
+		return u.Name != "dave"
+	}).Find(&filtered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filtered) != 2 {
+		t.Fatalf("expected 2 non-dave India users, got %d: %+v", len(filtered), filtered)
+	}
+
+	var again []queryTestUser
+	if err := d.Query("users").Eq("address.country", "India").Find(&again); err != nil {
+		t.Fatal(err)
+	}
+	if len(again) != 3 {
+		t.Fatalf("expected the cached index to still have all 3 India users, got %d: %+v", len(again), again)
+	}
+}
@@ -0,0 +1,264 @@
+package simpledb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const txDirPrefix = ".tx-"
+const txManifestName = "manifest.json"
+
+var txSeq uint64
+
+// txOp is one buffered mutation inside a transaction.
+type txOp struct {
+	kind       string // "write" or "delete"
+	collection string
+	resource   string
+	payload    []byte
+}
+
+// Tx buffers Write/Delete calls and applies them atomically on Commit,
+// using a stage-then-rename protocol so a crash mid-commit can always be
+// recovered into either the pre- or post-transaction state.
+type Tx struct {
+	driver *Driver
+	id     string
+
+	mu   sync.Mutex
+	ops  []txOp
+	done bool
+}
+
+// Begin starts a new transaction. Buffered mutations are not visible to
+// Read/Query until Commit succeeds.
+func (d *Driver) Begin() *Tx {
+	id := strconv.FormatUint(atomic.AddUint64(&txSeq, 1), 10)
+	return &Tx{driver: d, id: id}
+}
+
+// Write buffers a record write to be applied atomically on Commit. Schema
+// validation and OnBeforeWrite run here, against the same marshaled payload
+// that will be staged, so a transaction can't bypass an invariant a direct
+// Write would have enforced.
+func (tx *Tx) Write(collection, resource string, v interface{}) error {
+	if collection == "" || resource == "" {
+		return fmt.Errorf("collection and resource must not be empty when writing a record")
+	}
+	b, err := tx.driver.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.driver.validate(collection, resource, b); err != nil {
+		return err
+	}
+	if tx.driver.onBeforeWrite != nil {
+		if err := tx.driver.onBeforeWrite(collection, resource, b); err != nil {
+			return err
+		}
+	}
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.ops = append(tx.ops, txOp{kind: "write", collection: collection, resource: resource, payload: b})
+	return nil
+}
+
+// Delete buffers a record deletion to be applied atomically on Commit.
+// OnBeforeDelete runs here, matching direct Delete.
+func (tx *Tx) Delete(collection, resource string) error {
+	if collection == "" || resource == "" {
+		return fmt.Errorf("collection and resource must not be empty when deleting a record")
+	}
+
+	if tx.driver.onBeforeDelete != nil {
+		if err := tx.driver.onBeforeDelete(collection, resource); err != nil {
+			return err
+		}
+	}
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.ops = append(tx.ops, txOp{kind: "delete", collection: collection, resource: resource})
+	return nil
+}
+
+// txManifestEntry is one line of a committed manifest, naming the staged
+// payload (for writes) and the final path a commit moves it to.
+type txManifestEntry struct {
+	Op         string `json:"op"`
+	Collection string `json:"collection"`
+	Stage      string `json:"stage,omitempty"`
+	Final      string `json:"final"`
+}
+
+type txManifest struct {
+	ID      string            `json:"id"`
+	Entries []txManifestEntry `json:"entries"`
+}
+
+// Commit stages every buffered mutation into `.tx-<id>/`, writes a manifest
+// naming their final destinations, then renames each staged file into
+// place and removes the manifest. If the process crashes after the
+// manifest is written, Driver.Recover replays it to completion; if it
+// crashes before, the partial stage directory is simply discarded.
+func (tx *Tx) Commit() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return fmt.Errorf("transaction %s already committed", tx.id)
+	}
+	if len(tx.ops) == 0 {
+		tx.done = true
+		return nil
+	}
+
+	stageDir := filepath.Join(tx.driver.dir, txDirPrefix+tx.id)
+	if err := os.MkdirAll(stageDir, 0755); err != nil {
+		return err
+	}
+
+	manifest := txManifest{ID: tx.id}
+	for i, op := range tx.ops {
+		finalPath := filepath.Join(tx.driver.dir, op.collection, op.resource+tx.driver.codec.Ext())
+		entry := txManifestEntry{Op: op.kind, Collection: op.collection, Final: finalPath}
+
+		if op.kind == "write" {
+			if err := os.MkdirAll(filepath.Join(tx.driver.dir, op.collection), 0755); err != nil {
+				return err
+			}
+			stagePath := filepath.Join(stageDir, strconv.Itoa(i))
+			if err := ioutil.WriteFile(stagePath, op.payload, 0644); err != nil {
+				return err
+			}
+			entry.Stage = stagePath
+		}
+		manifest.Entries = append(manifest.Entries, entry)
+	}
+
+	manifestPath := filepath.Join(stageDir, txManifestName)
+	b, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(manifestPath, b, 0644); err != nil {
+		return err
+	}
+
+	if err := tx.driver.applyManifest(manifest); err != nil {
+		return err
+	}
+
+	tx.done = true
+	return os.RemoveAll(stageDir)
+}
+
+// applyManifest renames every staged write into place and removes every
+// deleted resource, taking the same per-resource locks Write/Delete use so
+// a transaction commit is mutually exclusive with direct calls. Every step
+// is idempotent, so replaying a manifest after a crash is always safe.
+func (d *Driver) applyManifest(manifest txManifest) error {
+	touched := make(map[string]bool)
+	for _, entry := range manifest.Entries {
+		resource := resourceNameFromFinal(entry.Final, d.codec.Ext())
+
+		collMu := d.getOrCreateMutex(entry.Collection)
+		collMu.RLock()
+		shard := d.shards.get(entry.Collection, resource)
+		shard.Lock()
+
+		var err error
+		var afterWrite []byte
+		switch entry.Op {
+		case "write":
+			err = os.Rename(entry.Stage, entry.Final)
+			if os.IsNotExist(err) {
+				// Already applied by a previous recovery pass.
+				err = nil
+			}
+			if err == nil && d.onAfterWrite != nil {
+				afterWrite, err = ioutil.ReadFile(entry.Final)
+			}
+		case "delete":
+			err = os.RemoveAll(entry.Final)
+		}
+
+		shard.Unlock()
+		collMu.RUnlock()
+
+		if err != nil {
+			return fmt.Errorf("applying tx entry for '%s': %w", entry.Final, err)
+		}
+		if entry.Op == "write" && d.onAfterWrite != nil {
+			d.onAfterWrite(entry.Collection, resource, afterWrite)
+		}
+		touched[entry.Collection] = true
+	}
+
+	for collection := range touched {
+		d.invalidateIndexes(collection)
+	}
+	return nil
+}
+
+func resourceNameFromFinal(final, ext string) string {
+	base := filepath.Base(final)
+	return base[:len(base)-len(ext)]
+}
+
+// recoverTransactions finds orphan `.tx-*` stage directories left behind by
+// a crash. A directory with a manifest is rolled forward to completion
+// (every step is idempotent); one without a manifest never got far enough
+// to touch any final file, so it's simply discarded.
+func (d *Driver) recoverTransactions() error {
+	entries, err := ioutil.ReadDir(d.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), txDirPrefix) {
+			continue
+		}
+		stageDir := filepath.Join(d.dir, entry.Name())
+
+		b, err := ioutil.ReadFile(filepath.Join(stageDir, txManifestName))
+		if err != nil {
+			if os.IsNotExist(err) {
+				if err := os.RemoveAll(stageDir); err != nil {
+					return err
+				}
+				continue
+			}
+			return err
+		}
+
+		var manifest txManifest
+		if err := json.Unmarshal(b, &manifest); err != nil {
+			// Torn manifest write; nothing we can trust was committed to.
+			if err := os.RemoveAll(stageDir); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := d.applyManifest(manifest); err != nil {
+			return err
+		}
+		if err := os.RemoveAll(stageDir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package simpledb
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTxCommitAppliesWritesAndDeletes(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write("users", "alice", map[string]string{"name": "alice"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := d.Begin()
+	if err := tx.Write("users", "bob", map[string]string{"name": "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Delete("users", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	var out map[string]string
+	if err := d.Read("users", "bob", &out); err != nil {
+		t.Fatalf("expected bob to be committed: %v", err)
+	}
+	if err := d.Read("users", "alice", &out); err == nil {
+		t.Fatal("expected alice to be deleted by the commit")
+	}
+}
+
+func TestTxCommitTwiceErrors(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := d.Begin()
+	if err := tx.Write("users", "bob", map[string]string{"name": "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err == nil {
+		t.Fatal("expected a second Commit to error")
+	}
+}
+
+func TestTxEmptyCommitIsNoOp(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := d.Begin()
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestTxRecoversAfterSimulatedCrash simulates a crash that happens after
+// Commit writes the stage directory and manifest but before it finishes
+// renaming every entry into place, by hand-building a stage directory the
+// same way Tx.Commit would and then opening a fresh Driver over the same
+// directory. New calls Recover, which calls recoverTransactions, which
+// should roll the manifest forward to completion.
+func TestTxRecoversAfterSimulatedCrash(t *testing.T) {
+	dir := t.TempDir()
+	d, err := New(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "users"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	stageDir := filepath.Join(dir, txDirPrefix+"crash-test")
+	if err := os.MkdirAll(stageDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	stagePath := filepath.Join(stageDir, "0")
+	payload := []byte(`{"name":"carol"}` + "\n")
+	if err := ioutil.WriteFile(stagePath, payload, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	finalPath := filepath.Join(dir, "users", "carol"+d.CodecExt())
+	manifest := txManifest{
+		ID: "crash-test",
+		Entries: []txManifestEntry{
+			{Op: "write", Collection: "users", Stage: stagePath, Final: finalPath},
+		},
+	}
+	b, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(stageDir, txManifestName), b, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The crash happened before the rename, so the final file doesn't exist
+	// yet and the stage directory is still around.
+	if _, err := os.Stat(finalPath); !os.IsNotExist(err) {
+		t.Fatalf("expected no final file before recovery, stat err = %v", err)
+	}
+
+	d2, err := New(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out map[string]string
+	if err := d2.Read("users", "carol", &out); err != nil {
+		t.Fatalf("expected recovery to roll the manifest forward: %v", err)
+	}
+	if out["name"] != "carol" {
+		t.Fatalf("unexpected recovered record: %+v", out)
+	}
+	if _, err := os.Stat(stageDir); !os.IsNotExist(err) {
+		t.Fatalf("expected the stage directory to be cleaned up, stat err = %v", err)
+	}
+}